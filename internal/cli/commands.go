@@ -1,11 +1,17 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/Kir-Push/GitID/internal/config"
+	"github.com/Kir-Push/GitID/internal/gitcmd"
+	"github.com/Kir-Push/GitID/internal/identity"
+	"github.com/Kir-Push/GitID/internal/scan"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -22,26 +28,59 @@ func expandPath(path string) (string, error) {
 	return filepath.Join(homeDir, path[2:]), nil
 }
 
-// findMatchingIdentities finds identities that match a given path.
-func findMatchingIdentities(testPath string) []string {
-	identities := identityManager.ListIdentities()
+// expandGitdirPath expands a leading ~/ and resolves the result to an
+// absolute path (see git-config(1)'s "Conditional includes"): git never
+// resolves a relative gitdir pattern like "." against the directory gitid
+// ran in; it prepends "**/" to it (or resolves "./" against the config
+// file's own directory) instead.
+func expandGitdirPath(path string) (string, error) {
+	expanded, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(expanded)
+}
+
+// findRepoRoot walks up from startDir looking for a .git directory, to find
+// the repository root for local/worktree scoped config. A .git file (as in a
+// linked worktree or a submodule) is not recognized.
+func findRepoRoot(startDir string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no .git directory found above %s", startDir)
+		}
+		dir = parent
+	}
+}
+
+// findMatchingIdentities finds identities whose includeIf conditions are
+// satisfied by ctx.
+func findMatchingIdentities(ctx identity.MatchContext, identities map[string]*identity.Identity) []string {
 	var matches []string
 
 	for name, ident := range identities {
-		for _, path := range ident.Paths {
-			if testPath == path || strings.HasPrefix(testPath, path+string(os.PathSeparator)) {
-				matches = append(matches, fmt.Sprintf("%s (%s)", name, ident.Email))
-			}
+		if ident.Matches(ctx) {
+			matches = append(matches, fmt.Sprintf("%s (%s, %s scope)", name, ident.Email, ident.Scope))
 		}
 	}
 	return matches
 }
 
-func printMatchesForPath(path string) {
-	matches := findMatchingIdentities(path)
+func printMatchesForPath(ctx identity.MatchContext, identities map[string]*identity.Identity) {
+	matches := findMatchingIdentities(ctx, identities)
 
 	if len(matches) == 0 {
-		color.Yellow("⚠️  No identity would apply to this path")
+		color.Yellow("⚠️  No identity would apply to this context")
 	} else {
 		color.Green("✅ Matching identities:")
 		for _, match := range matches {
@@ -71,45 +110,159 @@ var addCmd = &cobra.Command{
 		name := args[0]
 		gitName, _ := cmd.Flags().GetString("name")
 		email, _ := cmd.Flags().GetString("email")
-		paths, _ := cmd.Flags().GetStringArray("path")
-		expandedPaths := paths
+		scopeFlag, _ := cmd.Flags().GetString("scope")
+		signingKey, _ := cmd.Flags().GetString("signing-key")
+		signingFormat, _ := cmd.Flags().GetString("signing-format")
+		signCommits, _ := cmd.Flags().GetBool("sign-commits")
+		signTags, _ := cmd.Flags().GetBool("sign-tags")
+		sshKey, _ := cmd.Flags().GetString("ssh-key")
+
+		scope, err := identity.ParseScope(scopeFlag)
+		if err != nil {
+			color.Red("❌ Error: %v", err)
+			os.Exit(1)
+		}
 
-		if gitName == "" || email == "" || len(paths) == 0 {
-			color.Red("❌ Error: --name, --email, and --path are required")
+		if gitName == "" || email == "" {
+			color.Red("❌ Error: --name and --email are required")
 			os.Exit(1)
 		}
 
-		for i, path := range paths {
-			// Expand ~ in path
-			expandedPath, err := expandPath(path)
+		conditions, err := collectConditions(cmd)
+		if err != nil {
+			color.Red("❌ Error: %v", err)
+			os.Exit(1)
+		}
+		// Global identities are only ever picked up through an includeIf
+		// condition, so at least one is required; local/system/worktree
+		// identities are already scoped to a single repository/machine and
+		// don't need one.
+		if scope == identity.GlobalScope && len(conditions) == 0 {
+			color.Red("❌ Error: at least one of --gitdir, --gitdir-i, --onbranch, --remote-url is required for --scope global")
+			os.Exit(1)
+		}
+
+		var repoPath string
+		if scope == identity.LocalScope || scope == identity.WorktreeScope {
+			pathFlag, _ := cmd.Flags().GetString("path")
+			startDir := pathFlag
+			if startDir == "" {
+				pwd, err := os.Getwd()
+				if err != nil {
+					color.Red("❌ Failed to get current directory: %v", err)
+					os.Exit(1)
+				}
+				startDir = pwd
+			}
+			repoPath, err = findRepoRoot(startDir)
 			if err != nil {
-				color.Red("❌ Error expanding path: %v", err)
+				color.Red("❌ Error: %v", err)
 				os.Exit(1)
 			}
-			expandedPaths[i] = expandedPath
+		}
+
+		var sshCommand string
+		if sshKey != "" {
+			expandedKey, err := expandPath(sshKey)
+			if err != nil {
+				color.Red("❌ Error expanding --ssh-key: %v", err)
+				os.Exit(1)
+			}
+			sshKey = expandedKey
+			sshCommand = fmt.Sprintf("ssh -i %s", sshKey)
+			if signingFormat == "" {
+				signingFormat = "ssh"
+			}
+			if signingKey == "" {
+				signingKey = sshKey
+			}
+		}
+
+		ident := &identity.Identity{
+			Name:          name,
+			GitName:       gitName,
+			Email:         email,
+			Conditions:    conditions,
+			Scope:         scope,
+			RepoPath:      repoPath,
+			SigningKey:    signingKey,
+			SigningFormat: signingFormat,
+			SignCommits:   signCommits,
+			SignTags:      signTags,
+			SSHCommand:    sshCommand,
 		}
 
 		// Add identity (this handles both in-memory and config operations)
-		err := identityManager.AddIdentity(name, gitName, email, expandedPaths)
-		if err != nil {
+		if err := identityManager.AddIdentity(ident); err != nil {
 			color.Red("❌ Failed to add identity: %v", err)
 			os.Exit(1)
 		}
 
-		color.Green("✅ Added identity '%s'", name)
+		color.Green("✅ Added identity '%s' (%s scope)", name, scope)
 		fmt.Printf("   Name: %s\n", gitName)
 		fmt.Printf("   Email: %s\n", email)
-		fmt.Printf("   Paths: %s\n", expandedPaths)
+		for _, cond := range conditions {
+			fmt.Printf("   Condition: %s=%s\n", cond.Kind, cond.Pattern)
+		}
+		if signingKey != "" {
+			fmt.Printf("   Signing: %s (%s)\n", signingKey, signingFormat)
+		}
 	},
 }
 
+// collectConditions builds the includeIf conditions for `add` out of its
+// --gitdir/--gitdir-i/--onbranch/--remote-url flags.
+func collectConditions(cmd *cobra.Command) ([]identity.Condition, error) {
+	gitdirs, _ := cmd.Flags().GetStringArray("gitdir")
+	gitdirsCI, _ := cmd.Flags().GetStringArray("gitdir-i")
+	onBranches, _ := cmd.Flags().GetStringArray("onbranch")
+	remoteURLs, _ := cmd.Flags().GetStringArray("remote-url")
+
+	var conditions []identity.Condition
+	for _, path := range gitdirs {
+		expanded, err := expandGitdirPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("expanding --gitdir %q: %w", path, err)
+		}
+		conditions = append(conditions, identity.Condition{Kind: identity.ConditionGitdir, Pattern: expanded})
+	}
+	for _, path := range gitdirsCI {
+		expanded, err := expandGitdirPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("expanding --gitdir-i %q: %w", path, err)
+		}
+		conditions = append(conditions, identity.Condition{Kind: identity.ConditionGitdirCaseInsensitive, Pattern: expanded})
+	}
+	for _, branch := range onBranches {
+		conditions = append(conditions, identity.Condition{Kind: identity.ConditionOnBranch, Pattern: branch})
+	}
+	for _, remoteURL := range remoteURLs {
+		conditions = append(conditions, identity.Condition{Kind: identity.ConditionHasConfigRemoteURL, Pattern: remoteURL})
+	}
+
+	return conditions, nil
+}
+
 // listCmd lists all identities
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all identities",
 	Long:  "Display all configured Git identities and their settings.",
 	Run: func(cmd *cobra.Command, args []string) {
+		scopeFlag, _ := cmd.Flags().GetString("scope")
+
 		identities := identityManager.ListIdentities()
+		if scopeFlag == "all" {
+			pwd, _ := os.Getwd()
+			repoPath, _ := findRepoRoot(pwd) // best-effort; "" is fine outside a repo
+
+			merged, err := config.LoadIdentitiesAcrossScopes(repoPath)
+			if err != nil {
+				color.Red("❌ Failed to load identities across scopes: %v", err)
+				os.Exit(1)
+			}
+			identities = merged
+		}
 
 		if len(identities) == 0 {
 			color.Yellow("No identities configured. Use 'gitid add' to create one.")
@@ -118,15 +271,25 @@ var listCmd = &cobra.Command{
 
 		color.Blue("📋 Configured identities:")
 		for name, ident := range identities {
-			fmt.Printf("  %s\n", color.CyanString(name))
+			fmt.Printf("  %s (%s)\n", color.CyanString(name), ident.Scope)
 			fmt.Printf("    Name: %s\n", ident.GitName)
 			fmt.Printf("    Email: %s\n", ident.Email)
-			fmt.Printf("    Paths: %s\n", strings.Join(ident.Paths, ", "))
+			fmt.Printf("    Conditions: %s\n", formatConditions(ident.Conditions))
 			fmt.Println()
 		}
 	},
 }
 
+// formatConditions renders an identity's conditions the way they would be
+// spelled on the `add` CLI, e.g. "gitdir=~/work, onbranch=release/*".
+func formatConditions(conditions []identity.Condition) string {
+	parts := make([]string, len(conditions))
+	for i, cond := range conditions {
+		parts[i] = fmt.Sprintf("%s=%s", cond.Kind, cond.Pattern)
+	}
+	return strings.Join(parts, ", ")
+}
+
 // statusCmd shows current identity status
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -141,7 +304,28 @@ var statusCmd = &cobra.Command{
 
 		color.Blue("📍 Current directory: %s", pwd)
 
-		printMatchesForPath(pwd)
+		repoPath, _ := findRepoRoot(pwd) // best-effort; "" is fine outside a repo
+		merged, err := config.LoadIdentitiesAcrossScopes(repoPath)
+		if err != nil {
+			color.Red("❌ Failed to load identities across scopes: %v", err)
+			os.Exit(1)
+		}
+
+		printMatchesForPath(identity.MatchContext{Path: pwd}, merged)
+
+		if verify, _ := cmd.Flags().GetBool("verify"); verify {
+			fmt.Println()
+			color.Blue("Verifying against real git in %s:", pwd)
+			effective, err := readEffectiveGitConfig(cmd.Context(), pwd)
+			if err != nil {
+				color.Red("❌ %v", err)
+				os.Exit(1)
+			}
+			fmt.Printf("  user.name=%q user.email=%q\n", effective.name, effective.email)
+			if effective.emailOrigin != "" {
+				fmt.Printf("  user.email picked from: %s\n", effective.emailOrigin)
+			}
+		}
 	},
 }
 
@@ -149,10 +333,12 @@ var statusCmd = &cobra.Command{
 var testCmd = &cobra.Command{
 	Use:   "test [path]",
 	Short: "Test which identity applies to a path",
-	Long:  "Show which Git identity would be applied for the specified directory path.",
+	Long:  "Show which Git identity would be applied for the specified directory path, optionally simulating a checked-out branch or origin remote URL.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		testPath := args[0]
+		branch, _ := cmd.Flags().GetString("branch")
+		remoteURL, _ := cmd.Flags().GetString("remote-url")
 
 		// Expand ~ in path
 		expandedPath, err := expandPath(testPath)
@@ -163,8 +349,15 @@ var testCmd = &cobra.Command{
 		testPath = expandedPath
 
 		color.Blue("🔍 Testing path: %s", testPath)
+		if branch != "" {
+			fmt.Printf("   Simulated branch: %s\n", branch)
+		}
+		if remoteURL != "" {
+			fmt.Printf("   Simulated remote URL: %s\n", remoteURL)
+		}
 
-		printMatchesForPath(testPath)
+		ctx := identity.MatchContext{Path: testPath, Branch: branch, RemoteURL: remoteURL}
+		printMatchesForPath(ctx, identityManager.ListIdentities())
 	},
 }
 
@@ -188,9 +381,446 @@ var removeCmd = &cobra.Command{
 	},
 }
 
+// restoreCmd lists or restores GitID's automatic ~/.gitconfig backups.
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "List or restore automatic ~/.gitconfig backups",
+	Long: `GitID keeps a timestamped backup of ~/.gitconfig before every write. Run
+'gitid restore' with no flags to list them, or 'gitid restore --backup <name>'
+to restore one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cm, err := config.NewConfigManager()
+		if err != nil {
+			color.Red("❌ Failed to initialize config manager: %v", err)
+			os.Exit(1)
+		}
+
+		backupName, _ := cmd.Flags().GetString("backup")
+		if backupName == "" {
+			backups, err := config.ListBackups(cm.GitConfigPath())
+			if err != nil {
+				color.Red("❌ Failed to list backups: %v", err)
+				os.Exit(1)
+			}
+			if len(backups) == 0 {
+				color.Yellow("No backups found.")
+				return
+			}
+			fmt.Println("Available backups (oldest first):")
+			for _, b := range backups {
+				fmt.Printf("  %s\n", b)
+			}
+			fmt.Println("\nRestore one with: gitid restore --backup <name>")
+			return
+		}
+
+		if err := config.RestoreBackup(cm.GitConfigPath(), backupName); err != nil {
+			color.Red("❌ Failed to restore %s: %v", backupName, err)
+			os.Exit(1)
+		}
+		color.Green("✅ Restored %s from %s", cm.GitConfigPath(), backupName)
+	},
+}
+
+// scanCmd walks a directory tree for existing git repositories and reports
+// how each one classifies against the configured identities.
+var scanCmd = &cobra.Command{
+	Use:   "scan <root>",
+	Short: "Find existing git repositories and classify them against your identities",
+	Long: `Walk a directory tree looking for git repositories (respecting .gitignore
+and --max-depth), and classify each one: already matching a GitID identity by
+path, matching an identity by email but outside its declared path, using an
+email with no GitID identity, or locally shadowed by a .git/config override.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root, maxDepth, identities := scanArgs(cmd, args)
+
+		repos, err := scan.Walk(cmd.Context(), root, maxDepth, identities)
+		if err != nil {
+			color.Red("❌ Failed to scan %s: %v", root, err)
+			os.Exit(1)
+		}
+
+		if len(repos) == 0 {
+			color.Yellow("No git repositories found under %s", root)
+			return
+		}
+
+		for _, repo := range repos {
+			printScannedRepo(repo)
+		}
+	},
+}
+
+// migrateCmd performs the fixes scanCmd would suggest, non-interactively.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <root>",
+	Short: "Apply the fixes 'gitid scan' suggests",
+	Long: `Walk a directory tree like 'gitid scan', then for each repository apply the
+suggested fix: extend an identity's gitdir to cover a repo that matches by
+email, remove a locally shadowing user.email, or create a new identity from a
+repo's detected name/email. Use --dry-run to preview without changing
+anything, and --yes to skip the confirmation prompt.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root, maxDepth, identities := scanArgs(cmd, args)
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		repos, err := scan.Walk(cmd.Context(), root, maxDepth, identities)
+		if err != nil {
+			color.Red("❌ Failed to scan %s: %v", root, err)
+			os.Exit(1)
+		}
+
+		actionable := make([]scan.Repo, 0, len(repos))
+		for _, repo := range repos {
+			if repo.Classification != scan.MatchesByPath {
+				actionable = append(actionable, repo)
+			}
+		}
+
+		if len(actionable) == 0 {
+			color.Green("✅ Nothing to migrate under %s", root)
+			return
+		}
+
+		for _, repo := range actionable {
+			printScannedRepo(repo)
+		}
+
+		if dryRun {
+			return
+		}
+
+		if !yes && !confirmMigration(len(actionable)) {
+			color.Yellow("Aborted; no changes made.")
+			return
+		}
+
+		for _, repo := range actionable {
+			if err := applyMigration(cmd.Context(), repo); err != nil {
+				color.Red("❌ %s: %v", repo.Path, err)
+				continue
+			}
+			color.Green("✅ Fixed %s", repo.Path)
+		}
+	},
+}
+
+// scanArgs resolves the shared <root>/--max-depth/identities-across-scopes
+// arguments scanCmd and migrateCmd both start from.
+func scanArgs(cmd *cobra.Command, args []string) (root string, maxDepth int, identities map[string]*identity.Identity) {
+	// Resolved to an absolute path for the same reason --gitdir is
+	// (expandGitdirPath): repo.Path ends up as a gitdir condition pattern,
+	// and a relative one would have git auto-prepend "**/" and match far
+	// more broadly than the directory actually scanned.
+	root, err := expandGitdirPath(args[0])
+	if err != nil {
+		color.Red("❌ Error expanding path: %v", err)
+		os.Exit(1)
+	}
+
+	maxDepth, _ = cmd.Flags().GetInt("max-depth")
+
+	identities, err = config.LoadIdentitiesAcrossScopes(root)
+	if err != nil {
+		color.Red("❌ Failed to load identities across scopes: %v", err)
+		os.Exit(1)
+	}
+
+	return root, maxDepth, identities
+}
+
+func printScannedRepo(repo scan.Repo) {
+	fmt.Printf("%s\n", color.CyanString(repo.Path))
+	fmt.Printf("  user.name=%q user.email=%q\n", repo.Name, repo.Email)
+	fmt.Printf("  %s\n", repo.Classification)
+	if suggestion := suggestionFor(repo); suggestion != "" {
+		fmt.Printf("  suggestion: %s\n", suggestion)
+	}
+}
+
+func suggestionFor(repo scan.Repo) string {
+	switch repo.Classification {
+	case scan.MatchesByEmailOutsidePath:
+		return fmt.Sprintf("extend identity '%s' to cover %s", repo.Identity, repo.Path)
+	case scan.UnmanagedEmail:
+		return fmt.Sprintf("gitid add <name> --name %q --email %s --gitdir %s", repo.Name, repo.Email, repo.Path)
+	case scan.LocallyShadowed:
+		return fmt.Sprintf("remove the local user.email override in %s/.git/config so identity '%s' applies", repo.Path, repo.Identity)
+	default:
+		return ""
+	}
+}
+
+func confirmMigration(count int) bool {
+	fmt.Printf("Apply %d fix(es)? [y/N] ", count)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// applyMigration performs the fix scanCmd suggested for repo.
+func applyMigration(ctx context.Context, repo scan.Repo) error {
+	switch repo.Classification {
+	case scan.MatchesByEmailOutsidePath:
+		ident, err := identityManager.GetIdentity(repo.Identity)
+		if err != nil {
+			return err
+		}
+		ident.Conditions = append(ident.Conditions, identity.Condition{Kind: identity.ConditionGitdir, Pattern: repo.Path})
+		cm, err := configManagerFactory(ident.Scope, ident.RepoPath)
+		if err != nil {
+			return err
+		}
+		return cm.AddIncludeIf(ident)
+
+	case scan.LocallyShadowed:
+		_, _, err := gitcmd.Run(ctx, repo.Path, "config", "--local", "--unset", "user.email")
+		return err
+
+	case scan.UnmanagedEmail:
+		name := identityNameFromEmail(repo.Email)
+		ident := &identity.Identity{
+			Name:       name,
+			GitName:    repo.Name,
+			Email:      repo.Email,
+			Conditions: []identity.Condition{{Kind: identity.ConditionGitdir, Pattern: repo.Path}},
+			Scope:      identity.GlobalScope,
+		}
+		return identityManager.AddIdentity(ident)
+
+	default:
+		return nil
+	}
+}
+
+// identityNameFromEmail derives an identity name from the local part of an
+// email address, disambiguating against existing identities if needed.
+func identityNameFromEmail(email string) string {
+	base := email
+	if i := strings.Index(email, "@"); i >= 0 {
+		base = email[:i]
+	}
+
+	name := base
+	for i := 2; identityManager.ListIdentities()[name] != nil; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	return name
+}
+
+// doctorCmd verifies each identity's signing key is usable, and that a real
+// git invocation actually resolves the identity GitID thinks it should.
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Verify signing keys and effective git identity for every identity",
+	Long: `Check that each identity's signing key actually exists and can be used (a GPG
+key importable by gpg, or an SSH key loadable by ssh-keygen), and that a
+throwaway repository under each gitdir condition actually resolves
+user.name/user.email the way GitID expects when queried with real git.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		identities := identityManager.ListIdentities()
+		if len(identities) == 0 {
+			color.Yellow("No identities configured. Use 'gitid add' to create one.")
+			return
+		}
+
+		ctx := cmd.Context()
+		healthy := true
+		for name, ident := range identities {
+			color.Blue("%s:", name)
+
+			if ident.SigningKey == "" {
+				fmt.Println("  no signing key configured")
+			} else if err := checkSigningKey(ident); err != nil {
+				color.Red("  ❌ signing key: %v", err)
+				healthy = false
+			} else {
+				color.Green("  ✅ signing key OK")
+			}
+
+			if !verifyGitdirIdentity(ctx, ident) {
+				healthy = false
+			}
+		}
+
+		if !healthy {
+			os.Exit(1)
+		}
+	},
+}
+
+// verifyGitdirIdentity runs a throwaway `git init`ed repo under each of
+// ident's gitdir conditions and checks that real git resolves user.name and
+// user.email the way GitID expects, printing a green/red line per condition.
+// It returns false if any check failed.
+func verifyGitdirIdentity(ctx context.Context, ident *identity.Identity) bool {
+	ok := true
+	probed := false
+
+	for _, cond := range ident.Conditions {
+		if cond.Kind != identity.ConditionGitdir && cond.Kind != identity.ConditionGitdirCaseInsensitive {
+			continue
+		}
+		probed = true
+
+		effective, err := probeEffectiveIdentity(ctx, strings.TrimSuffix(cond.Pattern, "/"))
+		if err != nil {
+			color.Red("  ❌ %s: %v", cond.Key(), err)
+			ok = false
+			continue
+		}
+
+		if effective.name == ident.GitName && effective.email == ident.Email {
+			color.Green("  ✅ %s: git resolves user.name=%q user.email=%q", cond.Key(), effective.name, effective.email)
+		} else {
+			color.Red("  ❌ %s: git resolved user.name=%q user.email=%q, want %q/%q",
+				cond.Key(), effective.name, effective.email, ident.GitName, ident.Email)
+			ok = false
+		}
+		if effective.emailOrigin != "" {
+			fmt.Printf("     user.email picked from: %s\n", effective.emailOrigin)
+		}
+	}
+
+	if !probed {
+		fmt.Println("  no gitdir condition to verify against a real repository")
+	}
+
+	return ok
+}
+
+// effectiveGitConfig is what real git resolves for a directory, as opposed to
+// what GitID's in-memory model thinks should apply there.
+type effectiveGitConfig struct {
+	name        string
+	email       string
+	signingKey  string
+	emailOrigin string
+}
+
+// probeEffectiveIdentity creates a throwaway git repository under dir (so it
+// falls under any gitdir includeIf condition pointing at dir), queries real
+// git for the identity it resolves there, and cleans the probe repo up. dir
+// itself is never created: if it doesn't already exist, that's reported as
+// an error rather than materialized, since dir is a real user-supplied path
+// (e.g. ~/work) and doctor shouldn't have the side effect of creating it.
+func probeEffectiveIdentity(ctx context.Context, dir string) (effectiveGitConfig, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return effectiveGitConfig{}, fmt.Errorf("%s does not exist; skipping", dir)
+	}
+
+	probeDir, err := os.MkdirTemp(dir, ".gitid-doctor-probe-*")
+	if err != nil {
+		return effectiveGitConfig{}, fmt.Errorf("could not create probe repo under %s: %w", dir, err)
+	}
+	defer os.RemoveAll(probeDir)
+
+	if _, _, err := gitcmd.Run(ctx, probeDir, "init", "--quiet"); err != nil {
+		return effectiveGitConfig{}, err
+	}
+
+	return readEffectiveGitConfig(ctx, probeDir)
+}
+
+// readEffectiveGitConfig shells out to real git to read the identity it
+// resolves for dir, the way git itself would when making a commit there.
+func readEffectiveGitConfig(ctx context.Context, dir string) (effectiveGitConfig, error) {
+	name, err := getGitConfig(ctx, dir, "user.name")
+	if err != nil {
+		return effectiveGitConfig{}, err
+	}
+	email, err := getGitConfig(ctx, dir, "user.email")
+	if err != nil {
+		return effectiveGitConfig{}, err
+	}
+	signingKey, err := getGitConfig(ctx, dir, "user.signingkey")
+	if err != nil {
+		return effectiveGitConfig{}, err
+	}
+	emailOrigin, _, _ := gitcmd.Run(ctx, dir, "config", "--show-origin", "--get", "user.email")
+
+	return effectiveGitConfig{name: name, email: email, signingKey: signingKey, emailOrigin: emailOrigin}, nil
+}
+
+// getGitConfig reads a single config key, treating "unset" (git config's
+// exit code 1) as an empty value rather than an error.
+func getGitConfig(ctx context.Context, dir, key string) (string, error) {
+	value, _, err := gitcmd.Run(ctx, dir, "config", "--get", key)
+	if err != nil && !gitcmd.IsUnsetConfigErr(err) {
+		return "", err
+	}
+	return value, nil
+}
+
+// checkSigningKey verifies ident's signing key is usable for its signing format.
+func checkSigningKey(ident *identity.Identity) error {
+	switch ident.SigningFormat {
+	case "ssh":
+		return checkSSHKey(ident.SigningKey)
+	case "x509":
+		return nil // x509 certificates are managed outside of GitID
+	default:
+		return checkGPGKey(ident.SigningKey)
+	}
+}
+
+func checkSSHKey(path string) error {
+	expanded, err := expandPath(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(expanded); err != nil {
+		return fmt.Errorf("SSH key %s not found: %w", expanded, err)
+	}
+	if out, err := exec.Command("ssh-keygen", "-l", "-f", expanded).CombinedOutput(); err != nil {
+		return fmt.Errorf("ssh-keygen could not load %s: %s", expanded, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func checkGPGKey(key string) error {
+	if out, err := exec.Command("gpg", "--list-keys", key).CombinedOutput(); err != nil {
+		return fmt.Errorf("gpg does not recognize signing key %q: %s", key, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func init() {
 	// Add flags for add command
 	addCmd.Flags().StringP("name", "n", "", "Git user name")
 	addCmd.Flags().StringP("email", "e", "", "Git user email")
-	addCmd.Flags().StringArrayP("path", "p", []string{}, "Directory path for this identity")
+	addCmd.Flags().StringArray("gitdir", []string{}, "Apply when the repository is under this directory (includeIf gitdir:)")
+	addCmd.Flags().StringArray("gitdir-i", []string{}, "Like --gitdir, case-insensitively (includeIf gitdir/i:)")
+	addCmd.Flags().StringArray("onbranch", []string{}, "Apply when this branch is checked out (includeIf onbranch:)")
+	addCmd.Flags().StringArray("remote-url", []string{}, "Apply when the origin remote URL matches this pattern (includeIf hasconfig:remote.*.url:)")
+	addCmd.Flags().String("scope", "global", "Scope to write the identity into: global, system, local, worktree")
+	addCmd.Flags().String("path", "", "Repository path to resolve --scope local/worktree against (default: current directory)")
+	addCmd.Flags().String("signing-key", "", "user.signingkey for this identity")
+	addCmd.Flags().String("signing-format", "", "gpg.format for this identity: gpg, ssh or x509 (default gpg)")
+	addCmd.Flags().Bool("sign-commits", false, "Set commit.gpgsign for this identity")
+	addCmd.Flags().Bool("sign-tags", false, "Set tag.gpgsign for this identity")
+	addCmd.Flags().String("ssh-key", "", "Path to an SSH private key; sets core.sshCommand and implies --signing-format ssh")
+
+	// Add flags for list command
+	listCmd.Flags().String("scope", "", "Scope to list: leave empty for the in-memory global set, or \"all\" to merge system+global+local+worktree")
+
+	statusCmd.Flags().Bool("verify", false, "Additionally verify the effective identity by querying real git (git config --get)")
+
+	// Add flags for test command
+	testCmd.Flags().String("branch", "", "Simulate this branch being checked out")
+	testCmd.Flags().String("remote-url", "", "Simulate this origin remote URL")
+
+	// Add flags for scan/migrate commands
+	scanCmd.Flags().Int("max-depth", 0, "Maximum directory depth to descend (0 means unlimited)")
+	migrateCmd.Flags().Int("max-depth", 0, "Maximum directory depth to descend (0 means unlimited)")
+	migrateCmd.Flags().Bool("dry-run", false, "Print the fixes that would be applied without changing anything")
+	migrateCmd.Flags().Bool("yes", false, "Apply fixes without prompting for confirmation")
+
+	// Add flags for restore command
+	restoreCmd.Flags().String("backup", "", "Name of the backup to restore (see 'gitid restore' with no flags to list them)")
 }
@@ -9,10 +9,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	configManager   *config.ConfigManager
-	identityManager *identity.Manager
-)
+var identityManager *identity.Manager
+
+// configManagerFactory adapts config.NewConfigManagerForScope to the
+// identity.ConfigManagerFactory signature identity.Manager expects.
+func configManagerFactory(scope identity.Scope, repoPath string) (identity.ConfigManager, error) {
+	return config.NewConfigManagerForScope(scope, repoPath)
+}
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -23,8 +26,9 @@ making it easy to automatically use different Git identities based on directory
 
 Examples:
   gitid init                                          # Initialize GitID
-  gitid add work --name "John Doe" --email john@company.com --path ~/work
-  gitid list                                          # List all identities
+  gitid add work --name "John Doe" --email john@company.com --gitdir ~/work
+  gitid add ops --name "Ops Bot" --email ops@company.com --scope system --gitdir /srv   # write into /etc/gitconfig
+  gitid list --scope all                              # Merge identities across every scope
   gitid status                                        # Show current identity
   gitid test ~/work/project                          # Test which identity applies`,
 }
@@ -39,17 +43,17 @@ func Execute() {
 
 func init() {
 	// Initialize managers
-	var err error
-	configManager, err = config.NewConfigManager()
+	identityManager = identity.NewManager(configManagerFactory)
+
+	// Load existing global identities from git config. Scoped commands
+	// (list --scope all, status) merge in system/local/worktree on demand.
+	globalConfigManager, err := config.NewConfigManager()
 	if err != nil {
 		color.Red("Failed to initialize config manager: %v", err)
 		os.Exit(1)
 	}
 
-	identityManager = identity.NewManager(configManager)
-
-	// Load existing identities from git config
-	if existingIdentities, err := configManager.LoadExistingIdentities(); err != nil {
+	if existingIdentities, err := globalConfigManager.LoadExistingIdentities(); err != nil {
 		// Warn about errors but don't exit, so the app remains usable
 		// to create a new config or add identities.
 		color.Yellow("Warning: could not load existing identities: %v", err)
@@ -64,4 +68,8 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(restoreCmd)
 }
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// maxBackups is the number of timestamped backups writeFileAtomic keeps per
+// source file before pruning the oldest.
+const maxBackups = 10
+
+// backupDir is where writeFileAtomic copies the previous contents of a
+// gitconfig file before overwriting it, so `gitid restore` has something to
+// restore from if a write goes wrong.
+func backupDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "gitid", "backups"), nil
+}
+
+// writeFileAtomic writes data to path without ever leaving it truncated or
+// half-written: it takes an advisory lock on path, backs up path's previous
+// contents, writes data to a temp file in the same directory and fsyncs it,
+// then renames the temp file over path (atomic on the same filesystem). The
+// lock protects against two concurrent `gitid add` calls corrupting the file.
+func writeFileAtomic(path string, data []byte) error {
+	lock := flock.New(path + ".gitid.lock")
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer lock.Unlock()
+
+	if err := backupExisting(path); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	tmpPath := path + ".gitid.tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// backupExisting copies path's current contents to a timestamped file under
+// backupDir before it's overwritten, then prunes old backups beyond maxBackups.
+func backupExisting(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil // nothing to back up yet
+	}
+	if err != nil {
+		return err
+	}
+
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	name := buildBackupName(path, time.Now())
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+
+	return pruneBackups(dir, filepath.Base(path))
+}
+
+func buildBackupName(path string, at time.Time) string {
+	return fmt.Sprintf("%s-%s", filepath.Base(path), at.Format("20060102-150405"))
+}
+
+// pruneBackups keeps only the maxBackups most recent backups for the given
+// source file base name (e.g. ".gitconfig"), deleting older ones.
+func pruneBackups(dir, baseName string) error {
+	matches, err := backupsFor(dir, baseName)
+	if err != nil {
+		return err
+	}
+
+	for len(matches) > maxBackups {
+		if err := os.Remove(filepath.Join(dir, matches[0])); err != nil {
+			return err
+		}
+		matches = matches[1:]
+	}
+
+	return nil
+}
+
+func backupsFor(dir, baseName string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), baseName+"-") {
+			matches = append(matches, entry.Name())
+		}
+	}
+	sort.Strings(matches) // timestamp suffix sorts lexicographically = chronologically
+
+	return matches, nil
+}
+
+// ListBackups returns the available backup file names for path, oldest first.
+func ListBackups(path string) ([]string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := backupsFor(dir, filepath.Base(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return matches, err
+}
+
+// RestoreBackup restores the given backup file name (as returned by
+// ListBackups) over path, itself going through writeFileAtomic so a failed
+// restore can't corrupt path either.
+func RestoreBackup(path, backupName string) error {
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, backupName))
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
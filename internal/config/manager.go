@@ -1,97 +1,197 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/Kir-Push/GitID/internal/identity"
+	gitconfig "github.com/go-git/go-git/v5/plumbing/format/config"
 	"gopkg.in/ini.v1"
 )
 
 const (
 	GitIDSectionStart = "# GitID Managed Section - Do not edit manually"
 	GitIDSectionEnd   = "# End GitID Managed Section"
+
+	includeIfSectionName = "includeif"
+	pathOptionKey        = "path"
 )
 
+// SystemGitConfigPath is the well-known location of git's system-wide config.
+// It is a var, not a const, so tests can point it at a scratch file.
+var SystemGitConfigPath = "/etc/gitconfig"
+
 // ConfigManager handles git configuration operations
 type ConfigManager struct {
-	gitConfigPath string // ~/.gitconfig
+	scope         identity.Scope
+	gitConfigPath string // resolved gitconfig file for scope, e.g. ~/.gitconfig
 	identityDir   string // ~/.gitconfig-gitid-*
+	repoPathHint  string // repository root for LocalScope/WorktreeScope, tagged onto loaded identities
 }
 
-// NewConfigManager creates a new config manager
+// NewConfigManager creates a new config manager for GlobalScope (~/.gitconfig),
+// GitID's original behavior before scoped identities existed.
 func NewConfigManager() (*ConfigManager, error) {
+	return NewConfigManagerForScope(identity.GlobalScope, "")
+}
+
+// NewConfigManagerForScope creates a config manager targeting the gitconfig
+// file for scope. repoPath is required for identity.LocalScope and
+// identity.WorktreeScope, where it is the repository root.
+func NewConfigManagerForScope(scope identity.Scope, repoPath string) (*ConfigManager, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
 
+	gitConfigPath, err := scopeGitConfigPath(scope, repoPath, homeDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ConfigManager{
-		gitConfigPath: filepath.Join(homeDir, ".gitconfig"),
+		scope:         scope,
+		gitConfigPath: gitConfigPath,
 		identityDir:   homeDir,
+		repoPathHint:  repoPath,
 	}, nil
 }
 
+// scopeGitConfigPath resolves the gitconfig file backing scope.
+func scopeGitConfigPath(scope identity.Scope, repoPath, homeDir string) (string, error) {
+	switch scope {
+	case identity.GlobalScope:
+		return filepath.Join(homeDir, ".gitconfig"), nil
+	case identity.SystemScope:
+		return SystemGitConfigPath, nil
+	case identity.LocalScope:
+		if repoPath == "" {
+			return "", fmt.Errorf("local scope requires a repository path")
+		}
+		return filepath.Join(repoPath, ".git", "config"), nil
+	case identity.WorktreeScope:
+		if repoPath == "" {
+			return "", fmt.Errorf("worktree scope requires a repository path")
+		}
+		return filepath.Join(repoPath, ".git", "config.worktree"), nil
+	default:
+		return "", fmt.Errorf("unknown scope %v", scope)
+	}
+}
+
+// scopePrecedence lists scopes from lowest to highest precedence, matching
+// git's own config resolution order: a later scope overrides an earlier one
+// when they declare the same identity name.
+var scopePrecedence = []identity.Scope{
+	identity.SystemScope,
+	identity.GlobalScope,
+	identity.LocalScope,
+	identity.WorktreeScope,
+}
+
+// LoadIdentitiesAcrossScopes merges identities declared in the system,
+// global, local and worktree gitconfig files for the repository at repoPath
+// (repoPath may be empty if only system/global scopes are of interest). Each
+// returned Identity is tagged with the scope that produced it, and a name
+// declared in more than one scope resolves to its highest-precedence scope,
+// so callers like `status` can explain which file produced the active
+// identity.
+func LoadIdentitiesAcrossScopes(repoPath string) (map[string]*identity.Identity, error) {
+	merged := make(map[string]*identity.Identity)
+
+	for _, scope := range scopePrecedence {
+		cm, err := NewConfigManagerForScope(scope, repoPath)
+		if err != nil {
+			continue // e.g. local/worktree requested outside of a repository
+		}
+
+		scoped, err := cm.LoadExistingIdentities()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s identities: %w", scope, err)
+		}
+		for name, ident := range scoped {
+			merged[name] = ident
+		}
+	}
+
+	return merged, nil
+}
+
 // LoadExistingIdentities loads identities from existing git config files
 func (c *ConfigManager) LoadExistingIdentities() (map[string]*identity.Identity, error) {
 	identities := make(map[string]*identity.Identity)
 
-	content, err := c.readGitConfig()
+	cfg, err := c.readGitConfig()
 	if err != nil {
 		return identities, nil // Return empty if can't read
 	}
 
-	startIndex, endIndex := c.findGitIDSection(content)
-	if startIndex == -1 {
-		return identities, nil // No GitID section
-	}
-
-	// Parse includeIf entries in GitID section
-	currentIdentity := ""
-	var currentPaths []string
+	for _, sub := range cfg.Section(includeIfSectionName).Subsections {
+		cond, ok := identity.ParseCondition(sub.Name)
+		if !ok {
+			continue
+		}
 
-	for i := startIndex + 1; i < endIndex; i++ {
-		line := strings.TrimSpace(content[i])
+		name, ok := identityNameFromPathOption(sub.Option(pathOptionKey))
+		if !ok {
+			continue
+		}
 
-		// Match includeIf line: [includeIf "gitdir:/path/"]
-		if strings.HasPrefix(line, "[includeIf \"gitdir:") {
-			// Extract path from gitdir
-			start := strings.Index(line, "gitdir:") + 7
-			end := strings.LastIndex(line, "\"]")
-			if start < end {
-				path := line[start:end]
-				// Remove trailing slash
-				if strings.HasSuffix(path, "/") {
-					path = path[:len(path)-1]
-				}
-				currentPaths = append(currentPaths, path)
+		ident, ok := identities[name]
+		if !ok {
+			ident, err = c.loadIdentityFile(name)
+			if err != nil {
+				continue
 			}
+			ident.Scope = c.scope
+			ident.RepoPath = c.repoPathHint
+			identities[name] = ident
 		}
+		ident.Conditions = append(ident.Conditions, cond)
+	}
 
-		// Match path line: path = ~/.gitconfig-gitid-name
-		if strings.Contains(line, "path = ") && strings.Contains(line, ".gitconfig-gitid-") {
-			// Extract identity name from filename
-			parts := strings.Split(line, ".gitconfig-gitid-")
-			if len(parts) > 1 {
-				currentIdentity = strings.TrimSpace(parts[1])
-
-				// Load identity details from identity file
-				if ident, err := c.loadIdentityFile(currentIdentity); err == nil {
-					ident.Paths = currentPaths
-					identities[currentIdentity] = ident
-				}
-
-				// Reset for next identity
-				currentPaths = []string{}
-			}
+	// A local/system/worktree identity with no includeIf conditions is
+	// written directly as a `[user]` block (see writeDirectIdentity) rather
+	// than through an includeIf+identity-file indirection, since it's
+	// already scoped to a single repository/machine. GlobalScope is
+	// excluded here: ~/.gitconfig's own default `[user]` block is not a
+	// GitID identity, just the user's ordinary git config.
+	if len(identities) == 0 && c.scope != identity.GlobalScope {
+		if ident, ok := directIdentity(cfg, c.scope, c.repoPathHint); ok {
+			identities[ident.Name] = ident
 		}
 	}
 
 	return identities, nil
 }
 
+// directIdentity reads a direct `[user]` block written by writeDirectIdentity,
+// naming the identity after the scope it was written to (e.g. "local",
+// "system") since there is no identity file to carry its name.
+func directIdentity(cfg *gitconfig.Config, scope identity.Scope, repoPath string) (*identity.Identity, bool) {
+	userSection := cfg.Section("user")
+	email := userSection.Option("email")
+	if email == "" {
+		return nil, false
+	}
+
+	return &identity.Identity{
+		Name:          scope.String(),
+		GitName:       userSection.Option("name"),
+		Email:         email,
+		Scope:         scope,
+		RepoPath:      repoPath,
+		SigningKey:    userSection.Option("signingkey"),
+		SigningFormat: cfg.Section("gpg").Option("format"),
+		SignCommits:   cfg.Section("commit").Option("gpgsign") == "true",
+		SignTags:      cfg.Section("tag").Option("gpgsign") == "true",
+		SSHCommand:    cfg.Section("core").Option("sshCommand"),
+	}, true
+}
+
 // loadIdentityFile loads an identity from its config file
 func (c *ConfigManager) loadIdentityFile(name string) (*identity.Identity, error) {
 	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", name))
@@ -109,34 +209,58 @@ func (c *ConfigManager) loadIdentityFile(name string) (*identity.Identity, error
 		Name:    name,
 		GitName: gitName,
 		Email:   email,
-		Paths:   []string{}, // Will be set by caller
+		// Conditions will be appended by the caller as it walks includeIf subsections.
+		SigningKey:    userSection.Key("signingkey").String(),
+		SigningFormat: cfg.Section("gpg").Key("format").String(),
+		SignCommits:   cfg.Section("commit").Key("gpgsign").MustBool(false),
+		SignTags:      cfg.Section("tag").Key("gpgsign").MustBool(false),
+		SSHCommand:    cfg.Section("core").Key("sshCommand").String(),
 	}, nil
 }
 
-// AddIncludeIf adds an includeIf entry to ~/.gitconfig and creates identity file
-func (c *ConfigManager) AddIncludeIf(identity *identity.Identity) error {
+// AddIncludeIf persists identity into this manager's gitconfig file. An
+// identity with no conditions (only possible for local/system/worktree
+// scopes, which are already scoped to a single repository/machine) is
+// written directly as a `[user]` block; otherwise it gets an identity file
+// plus one includeIf entry per condition.
+func (c *ConfigManager) AddIncludeIf(ident *identity.Identity) error {
+	if c.scope == identity.WorktreeScope {
+		if err := c.ensureWorktreeConfigExtension(); err != nil {
+			return fmt.Errorf("failed to enable extensions.worktreeConfig: %w", err)
+		}
+	}
+
+	if len(ident.Conditions) == 0 {
+		return c.writeDirectIdentity(ident)
+	}
+
 	// 1. Create identity config file
-	if err := c.createIdentityFile(identity); err != nil {
+	if err := c.createIdentityFile(ident); err != nil {
 		return fmt.Errorf("failed to create identity file: %w", err)
 	}
 
 	// 2. Add includeIf entry to ~/.gitconfig
-	if err := c.addIncludeIfEntry(identity); err != nil {
+	if err := c.addIncludeIfEntry(ident); err != nil {
 		return fmt.Errorf("failed to add includeIf entry: %w", err)
 	}
 
 	return nil
 }
 
-// RemoveIncludeIf removes an includeIf entry from ~/.gitconfig and deletes identity file
-func (c *ConfigManager) RemoveIncludeIf(name string) error {
+// RemoveIncludeIf undoes whichever of AddIncludeIf's two persistence
+// strategies was used for ident, based on whether it has any conditions.
+func (c *ConfigManager) RemoveIncludeIf(ident *identity.Identity) error {
+	if len(ident.Conditions) == 0 {
+		return c.removeDirectIdentity()
+	}
+
 	// 1. Remove includeIf entry from ~/.gitconfig
-	if err := c.removeIncludeIfEntry(name); err != nil {
+	if err := c.removeIncludeIfEntry(ident.Name); err != nil {
 		return fmt.Errorf("failed to remove includeIf entry: %w", err)
 	}
 
 	// 2. Delete identity file
-	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", name))
+	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", ident.Name))
 	if err := os.Remove(identityFile); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove identity file: %w", err)
 	}
@@ -144,159 +268,314 @@ func (c *ConfigManager) RemoveIncludeIf(name string) error {
 	return nil
 }
 
-// createIdentityFile creates ~/.gitconfig-gitid-{name} file
-func (c *ConfigManager) createIdentityFile(identity *identity.Identity) error {
-	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", identity.Name))
+// ensureWorktreeConfigExtension sets extensions.worktreeConfig = true in the
+// repository's main .git/config. Git only reads .git/config.worktree at all
+// when that extension is enabled (see git-config(1)'s "extensions" section);
+// without it, anything GitID writes to WorktreeScope is silently ignored.
+func (c *ConfigManager) ensureWorktreeConfigExtension() error {
+	localConfigPath := filepath.Join(c.repoPathHint, ".git", "config")
+
+	cfg := gitconfig.New()
+	data, err := os.ReadFile(localConfigPath)
+	switch {
+	case err == nil:
+		if err := gitconfig.NewDecoder(strings.NewReader(string(data))).Decode(cfg); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", localConfigPath, err)
+		}
+	case os.IsNotExist(err):
+		return fmt.Errorf("%s does not exist", localConfigPath)
+	default:
+		return err
+	}
 
-	cfg := ini.Empty()
-	userSection, err := cfg.NewSection("user")
+	if cfg.Section("extensions").Option("worktreeConfig") == "true" {
+		return nil
+	}
+	cfg.Section("extensions").SetOption("worktreeConfig", "true")
+
+	var buf strings.Builder
+	if err := gitconfig.NewEncoder(&buf).Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode git config: %w", err)
+	}
+	return writeFileAtomic(localConfigPath, []byte(buf.String()))
+}
+
+// writeDirectIdentity writes ident straight into this manager's gitconfig
+// file as a `[user]` (plus gpg/commit/tag/core as needed) block, with no
+// includeIf indirection. This is the form `git config --local user.email
+// ...` itself would produce, so it's the natural representation for an
+// identity that's already confined to one repository or machine.
+func (c *ConfigManager) writeDirectIdentity(ident *identity.Identity) error {
+	cfg, err := c.readGitConfig()
 	if err != nil {
 		return err
 	}
 
-	userSection.NewKey("name", identity.GitName)
-	userSection.NewKey("email", identity.Email)
+	userSection := cfg.Section("user")
+	userSection.SetOption("name", ident.GitName)
+	userSection.SetOption("email", ident.Email)
+	if ident.SigningKey != "" {
+		userSection.SetOption("signingkey", ident.SigningKey)
+	}
+	if ident.SigningFormat != "" {
+		cfg.Section("gpg").SetOption("format", ident.SigningFormat)
+	}
+	if ident.SignCommits {
+		cfg.Section("commit").SetOption("gpgsign", "true")
+	}
+	if ident.SignTags {
+		cfg.Section("tag").SetOption("gpgsign", "true")
+	}
+	if ident.SSHCommand != "" {
+		cfg.Section("core").SetOption("sshCommand", ident.SSHCommand)
+	}
 
-	return cfg.SaveTo(identityFile)
+	return c.writeGitConfig(cfg)
 }
 
-// addIncludeIfEntry adds includeIf entries to ~/.gitconfig
-func (c *ConfigManager) addIncludeIfEntry(identity *identity.Identity) error {
-	content, err := c.readGitConfig()
+// removeDirectIdentity undoes writeDirectIdentity, clearing the `[user]`,
+// `[gpg]`, `[commit]` and `[tag]`/`[core]` options it may have set.
+func (c *ConfigManager) removeDirectIdentity() error {
+	cfg, err := c.readGitConfig()
 	if err != nil {
 		return err
 	}
 
-	// Find or create GitID managed section
-	startIndex, endIndex := c.findGitIDSection(content)
+	removeOption(cfg.Section("user"), "name")
+	removeOption(cfg.Section("user"), "email")
+	removeOption(cfg.Section("user"), "signingkey")
+	removeOption(cfg.Section("gpg"), "format")
+	removeOption(cfg.Section("commit"), "gpgsign")
+	removeOption(cfg.Section("tag"), "gpgsign")
+	removeOption(cfg.Section("core"), "sshCommand")
 
-	var newEntries []string
-	for _, path := range identity.Paths {
-		// Expand ~ to home directory
-		expandedPath := path
-		if strings.HasPrefix(path, "~/") {
-			homeDir, _ := os.UserHomeDir()
-			expandedPath = filepath.Join(homeDir, path[2:])
-		}
+	return c.writeGitConfig(cfg)
+}
 
-		// Ensure path ends with / for gitdir matching
-		if !strings.HasSuffix(expandedPath, "/") {
-			expandedPath += "/"
+// removeOption deletes key from section. go-git's config.Section has no
+// RemoveOption method, so this filters its Options slice directly.
+func removeOption(section *gitconfig.Section, key string) {
+	kept := section.Options[:0]
+	for _, opt := range section.Options {
+		if !strings.EqualFold(opt.Key, key) {
+			kept = append(kept, opt)
 		}
+	}
+	section.Options = kept
+}
+
+// createIdentityFile creates ~/.gitconfig-gitid-{name} file
+func (c *ConfigManager) createIdentityFile(ident *identity.Identity) error {
+	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", ident.Name))
+
+	cfg := ini.Empty()
+	userSection, err := cfg.NewSection("user")
+	if err != nil {
+		return err
+	}
+
+	userSection.NewKey("name", ident.GitName)
+	userSection.NewKey("email", ident.Email)
+	if ident.SigningKey != "" {
+		userSection.NewKey("signingkey", ident.SigningKey)
+	}
 
-		newEntries = append(newEntries, fmt.Sprintf("[includeIf \"gitdir:%s\"]", expandedPath))
-		newEntries = append(newEntries, fmt.Sprintf("    path = %s",
-			filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", identity.Name))))
+	if ident.SigningFormat != "" {
+		gpgSection, err := cfg.NewSection("gpg")
+		if err != nil {
+			return err
+		}
+		gpgSection.NewKey("format", ident.SigningFormat)
 	}
 
-	var newContent []string
+	if ident.SignCommits {
+		commitSection, err := cfg.NewSection("commit")
+		if err != nil {
+			return err
+		}
+		commitSection.NewKey("gpgsign", "true")
+	}
 
-	if startIndex == -1 {
-		// No GitID section exists, add it at the end
-		newContent = append(content, "")
-		newContent = append(newContent, GitIDSectionStart)
-		newContent = append(newContent, newEntries...)
-		newContent = append(newContent, GitIDSectionEnd)
-	} else {
-		// Preserve existing entries and add new ones
-		var existingEntries []string
-		for i := startIndex + 1; i < endIndex; i++ {
-			existingEntries = append(existingEntries, content[i])
+	if ident.SignTags {
+		tagSection, err := cfg.NewSection("tag")
+		if err != nil {
+			return err
 		}
+		tagSection.NewKey("gpgsign", "true")
+	}
 
-		newContent = append(content[:startIndex], GitIDSectionStart)
-		newContent = append(newContent, existingEntries...)
-		newContent = append(newContent, newEntries...)
-		newContent = append(newContent, GitIDSectionEnd)
-		if endIndex < len(content) {
-			newContent = append(newContent, content[endIndex+1:]...)
+	if ident.SSHCommand != "" {
+		coreSection, err := cfg.NewSection("core")
+		if err != nil {
+			return err
 		}
+		coreSection.NewKey("sshCommand", ident.SSHCommand)
 	}
 
-	return c.writeGitConfig(newContent)
+	return cfg.SaveTo(identityFile)
 }
 
-// removeIncludeIfEntry removes includeIf entries for a specific identity
-func (c *ConfigManager) removeIncludeIfEntry(name string) error {
-	content, err := c.readGitConfig()
+// addIncludeIfEntry adds includeIf entries to this manager's gitconfig file
+// (e.g. ~/.gitconfig for GlobalScope), one per condition.
+func (c *ConfigManager) addIncludeIfEntry(ident *identity.Identity) error {
+	cfg, err := c.readGitConfig()
 	if err != nil {
 		return err
 	}
 
-	startIndex, endIndex := c.findGitIDSection(content)
-	if startIndex == -1 {
-		return nil // No GitID section exists
+	identityFile := filepath.Join(c.identityDir, fmt.Sprintf(".gitconfig-gitid-%s", ident.Name))
+
+	for _, cond := range ident.Conditions {
+		cond = expandGitdirCondition(cond)
+		sub := cfg.Section(includeIfSectionName).Subsection(cond.Key())
+		sub.SetOption(pathOptionKey, identityFile)
 	}
 
-	// Filter out entries for this identity
-	var newEntries []string
+	return c.writeGitConfig(cfg)
+}
+
+// expandGitdirCondition expands a leading ~/ and ensures the trailing slash
+// gitdir matching requires; other condition kinds are returned unchanged.
+func expandGitdirCondition(cond identity.Condition) identity.Condition {
+	if cond.Kind != identity.ConditionGitdir && cond.Kind != identity.ConditionGitdirCaseInsensitive {
+		return cond
+	}
+
+	path := cond.Pattern
+	if strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, path[2:])
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	return identity.Condition{Kind: cond.Kind, Pattern: path}
+}
+
+// removeIncludeIfEntry removes includeIf entries for a specific identity
+func (c *ConfigManager) removeIncludeIfEntry(name string) error {
+	cfg, err := c.readGitConfig()
+	if err != nil {
+		return err
+	}
+
+	includeIf := cfg.Section(includeIfSectionName)
 	identityPath := fmt.Sprintf(".gitconfig-gitid-%s", name)
 
-	i := startIndex + 1
-	for i < endIndex {
-		line := content[i]
-		// Check if this is a path line for the identity we're removing
-		if strings.Contains(line, identityPath) {
-			// Skip this path line and the preceding includeIf line
-			if i > startIndex+1 {
-				newEntries = newEntries[:len(newEntries)-1] // Remove the includeIf line
-			}
-		} else {
-			newEntries = append(newEntries, line)
-		}
-		i++
-	}
-
-	var newContent []string
-	if len(newEntries) == 0 {
-		// Remove entire GitID section if no entries remain
-		newContent = append(content[:startIndex], content[endIndex+1:]...)
-	} else {
-		// Replace with filtered entries
-		newContent = append(content[:startIndex], GitIDSectionStart)
-		newContent = append(newContent, newEntries...)
-		newContent = append(newContent, GitIDSectionEnd)
-		if endIndex < len(content) {
-			newContent = append(newContent, content[endIndex+1:]...)
+	var toRemove []string
+	for _, sub := range includeIf.Subsections {
+		if strings.Contains(sub.Option(pathOptionKey), identityPath) {
+			toRemove = append(toRemove, sub.Name)
 		}
 	}
+	for _, subName := range toRemove {
+		includeIf.RemoveSubsection(subName)
+	}
 
-	return c.writeGitConfig(newContent)
+	return c.writeGitConfig(cfg)
 }
 
-// readGitConfig reads ~/.gitconfig and returns lines
-func (c *ConfigManager) readGitConfig() ([]string, error) {
-	if _, err := os.Stat(c.gitConfigPath); os.IsNotExist(err) {
-		return []string{}, nil // Return empty if file doesn't exist
+// identityNameFromPathOption extracts the identity name out of a `path = ~/.gitconfig-gitid-<name>` option.
+func identityNameFromPathOption(pathOption string) (string, bool) {
+	parts := strings.Split(pathOption, ".gitconfig-gitid-")
+	if len(parts) < 2 {
+		return "", false
 	}
+	name := strings.TrimSpace(parts[len(parts)-1])
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// readGitConfig reads this manager's gitconfig file (e.g. ~/.gitconfig for
+// GlobalScope) and decodes it with go-git's config parser.
+func (c *ConfigManager) readGitConfig() (*gitconfig.Config, error) {
+	cfg := gitconfig.New()
 
 	data, err := os.ReadFile(c.gitConfigPath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return strings.Split(string(data), "\n"), nil
+	if err := gitconfig.NewDecoder(strings.NewReader(string(data))).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", c.gitConfigPath, err)
+	}
+
+	return cfg, nil
+}
+
+// writeGitConfig encodes cfg back to this manager's gitconfig file, wrapping the includeIf
+// subsections GitID manages with human-readable `# GitID Managed Section`
+// comments so the file remains easy to eyeball by hand. The write itself goes
+// through writeFileAtomic, which locks, backs up and atomically replaces the
+// file so a crash mid-write can't leave it truncated.
+//
+// KNOWN LIMITATION: go-git's config encoder has no model of comments outside
+// the ones this function adds back, so any comment a user hand-wrote into
+// their gitconfig (other than the GitID markers) is lost on a round-trip
+// through readGitConfig/writeGitConfig. To limit how often that bites, this
+// skips the write entirely when the re-encoded content is byte-identical to
+// what's already on disk.
+func (c *ConfigManager) writeGitConfig(cfg *gitconfig.Config) error {
+	var buf strings.Builder
+	if err := gitconfig.NewEncoder(&buf).Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode git config: %w", err)
+	}
+	out := []byte(annotateGitIDSection(buf.String()))
+
+	if existing, err := os.ReadFile(c.gitConfigPath); err == nil && bytes.Equal(existing, out) {
+		return nil
+	}
+
+	return writeFileAtomic(c.gitConfigPath, out)
 }
 
-// writeGitConfig writes lines to ~/.gitconfig
-func (c *ConfigManager) writeGitConfig(lines []string) error {
-	content := strings.Join(lines, "\n")
-	return os.WriteFile(c.gitConfigPath, []byte(content), 0644)
+// GitConfigPath returns the gitconfig file this manager reads and writes,
+// e.g. ~/.gitconfig for GlobalScope. Used by `gitid restore` to know which
+// file's backups to list.
+func (c *ConfigManager) GitConfigPath() string {
+	return c.gitConfigPath
 }
 
-// findGitIDSection finds the GitID managed section in the config
-func (c *ConfigManager) findGitIDSection(lines []string) (int, int) {
-	startIndex := -1
-	endIndex := -1
+// annotateGitIDSection wraps the `[includeIf "gitdir:..."]` blocks that point at
+// a GitID identity file with the GitID marker comments. The encoder itself has
+// no notion of comments, so this is a purely cosmetic pass over its output.
+func annotateGitIDSection(encoded string) string {
+	lines := strings.Split(encoded, "\n")
 
+	firstManaged, lastManaged := -1, -1
 	for i, line := range lines {
-		if strings.TrimSpace(line) == GitIDSectionStart {
-			startIndex = i
-		} else if strings.TrimSpace(line) == GitIDSectionEnd {
-			endIndex = i
-			break
+		if strings.Contains(line, ".gitconfig-gitid-") {
+			// The includeIf header line for this path entry is the nearest
+			// preceding `[includeIf "gitdir:`/etc. line. Section names are
+			// case-insensitive, and the encoder writes a section GitID
+			// created itself as `[includeif ...]`, so match case-insensitively.
+			header := i
+			for header > 0 && !strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[header])), "[includeif ") {
+				header--
+			}
+			if firstManaged == -1 {
+				firstManaged = header
+			}
+			lastManaged = i
 		}
 	}
 
-	return startIndex, endIndex
+	if firstManaged == -1 {
+		return encoded
+	}
+
+	var out []string
+	out = append(out, lines[:firstManaged]...)
+	out = append(out, GitIDSectionStart)
+	out = append(out, lines[firstManaged:lastManaged+1]...)
+	out = append(out, GitIDSectionEnd)
+	out = append(out, lines[lastManaged+1:]...)
+
+	return strings.Join(out, "\n")
 }
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kir-Push/GitID/internal/identity"
+)
+
+func newTestManager(t *testing.T) *ConfigManager {
+	t.Helper()
+	dir := t.TempDir()
+	return &ConfigManager{
+		gitConfigPath: filepath.Join(dir, ".gitconfig"),
+		identityDir:   dir,
+	}
+}
+
+func writeIdentityFile(t *testing.T, mgr *ConfigManager, name, gitName, email string) {
+	t.Helper()
+	content := "[user]\n\tname = " + gitName + "\n\temail = " + email + "\n"
+	path := filepath.Join(mgr.identityDir, ".gitconfig-gitid-"+name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write identity file: %v", err)
+	}
+}
+
+func TestLoadExistingIdentities_WeirdButValidGitConfig(t *testing.T) {
+	mgr := newTestManager(t)
+	writeIdentityFile(t, mgr, "work", "Jane Doe", "jane@work.example")
+
+	// Tabs instead of spaces, a comment inside the block, CRLF line endings,
+	// and an unrelated includeIf section outside our managed block.
+	raw := "" +
+		"[includeIf \"gitdir:~/oss/\"]\r\n" +
+		"\tpath = ~/.gitconfig-oss\r\n" +
+		"\r\n" +
+		"# GitID Managed Section - Do not edit manually\r\n" +
+		"[includeIf \"gitdir:" + mgr.identityDir + "/work/\"]\r\n" +
+		"\t; a stray comment some other tool left behind\r\n" +
+		"\tpath=" + filepath.Join(mgr.identityDir, ".gitconfig-gitid-work") + "\r\n" +
+		"# End GitID Managed Section\r\n"
+
+	if err := os.WriteFile(mgr.gitConfigPath, []byte(raw), 0644); err != nil {
+		t.Fatalf("failed to write gitconfig: %v", err)
+	}
+
+	identities, err := mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+
+	ident, ok := identities["work"]
+	if !ok {
+		t.Fatalf("expected identity %q to be loaded, got %v", "work", identities)
+	}
+	if ident.Email != "jane@work.example" {
+		t.Errorf("Email = %q, want %q", ident.Email, "jane@work.example")
+	}
+	if len(ident.Conditions) != 1 || ident.Conditions[0].Kind != identity.ConditionGitdir ||
+		!strings.HasSuffix(ident.Conditions[0].Pattern, "/work/") {
+		t.Errorf("Conditions = %v, want single gitdir condition ending in /work/", ident.Conditions)
+	}
+	if _, ok := identities["oss"]; ok {
+		t.Errorf("unrelated includeIf entry should not be parsed as a GitID identity")
+	}
+}
+
+func TestAddThenRemoveIncludeIf_RoundTrips(t *testing.T) {
+	mgr := newTestManager(t)
+
+	ident := &identity.Identity{
+		Name:    "personal",
+		GitName: "Jane Doe",
+		Email:   "jane@personal.example",
+		Conditions: []identity.Condition{
+			{Kind: identity.ConditionGitdir, Pattern: filepath.Join(mgr.identityDir, "personal")},
+		},
+	}
+
+	if err := mgr.AddIncludeIf(ident); err != nil {
+		t.Fatalf("AddIncludeIf returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(mgr.gitConfigPath)
+	if err != nil {
+		t.Fatalf("failed to read gitconfig: %v", err)
+	}
+	if !strings.Contains(string(data), GitIDSectionStart) || !strings.Contains(string(data), GitIDSectionEnd) {
+		t.Errorf("expected GitID markers around managed section, got:\n%s", data)
+	}
+
+	loaded, err := mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+	if _, ok := loaded["personal"]; !ok {
+		t.Fatalf("expected 'personal' identity after AddIncludeIf, got %v", loaded)
+	}
+
+	if err := mgr.RemoveIncludeIf(ident); err != nil {
+		t.Fatalf("RemoveIncludeIf returned error: %v", err)
+	}
+
+	loaded, err = mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+	if _, ok := loaded["personal"]; ok {
+		t.Errorf("expected 'personal' identity to be removed, got %v", loaded)
+	}
+}
+
+func TestAddIncludeIf_WorktreeScopeEnablesExtension(t *testing.T) {
+	mgr := newTestManager(t)
+	repoDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoDir, ".git"), 0755); err != nil {
+		t.Fatalf("failed to create .git dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".git", "config"), []byte("[core]\n\trepositoryformatversion = 0\n"), 0644); err != nil {
+		t.Fatalf("failed to write .git/config: %v", err)
+	}
+	mgr.scope = identity.WorktreeScope
+	mgr.repoPathHint = repoDir
+
+	ident := &identity.Identity{
+		Name:     "worktree",
+		GitName:  "Jane Doe",
+		Email:    "jane@worktree.example",
+		Scope:    identity.WorktreeScope,
+		RepoPath: repoDir,
+	}
+
+	if err := mgr.AddIncludeIf(ident); err != nil {
+		t.Fatalf("AddIncludeIf returned error: %v", err)
+	}
+
+	localConfig, err := os.ReadFile(filepath.Join(repoDir, ".git", "config"))
+	if err != nil {
+		t.Fatalf("failed to read .git/config: %v", err)
+	}
+	if !strings.Contains(string(localConfig), "worktreeConfig") {
+		t.Errorf("expected extensions.worktreeConfig to be set in .git/config, got:\n%s", localConfig)
+	}
+}
+
+func TestAddThenRemoveIncludeIf_DirectUserBlock(t *testing.T) {
+	mgr := newTestManager(t)
+	mgr.scope = identity.LocalScope
+	mgr.repoPathHint = "/repo"
+
+	ident := &identity.Identity{
+		Name:     "local",
+		GitName:  "Jane Doe",
+		Email:    "jane@local.example",
+		Scope:    identity.LocalScope,
+		RepoPath: "/repo",
+	}
+
+	if err := mgr.AddIncludeIf(ident); err != nil {
+		t.Fatalf("AddIncludeIf returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(mgr.gitConfigPath)
+	if err != nil {
+		t.Fatalf("failed to read gitconfig: %v", err)
+	}
+	if !strings.Contains(string(data), "jane@local.example") {
+		t.Errorf("expected a direct [user] block with the identity's email, got:\n%s", data)
+	}
+	if strings.Contains(string(data), "includeIf") {
+		t.Errorf("a conditionless identity should not produce an includeIf entry, got:\n%s", data)
+	}
+
+	loaded, err := mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+	got, ok := loaded["local"]
+	if !ok {
+		t.Fatalf("expected identity 'local' after AddIncludeIf, got %v", loaded)
+	}
+	if got.Email != "jane@local.example" {
+		t.Errorf("Email = %q, want %q", got.Email, "jane@local.example")
+	}
+
+	if err := mgr.RemoveIncludeIf(ident); err != nil {
+		t.Fatalf("RemoveIncludeIf returned error: %v", err)
+	}
+
+	loaded, err = mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+	if _, ok := loaded["local"]; ok {
+		t.Errorf("expected 'local' identity to be removed, got %v", loaded)
+	}
+}
+
+func TestAddIncludeIf_OnBranchAndRemoteURLConditions(t *testing.T) {
+	mgr := newTestManager(t)
+
+	ident := &identity.Identity{
+		Name:    "oss",
+		GitName: "Jane Doe",
+		Email:   "jane@oss.example",
+		Conditions: []identity.Condition{
+			{Kind: identity.ConditionOnBranch, Pattern: "release/*"},
+			{Kind: identity.ConditionHasConfigRemoteURL, Pattern: "git@github.com:jane/*"},
+		},
+	}
+
+	if err := mgr.AddIncludeIf(ident); err != nil {
+		t.Fatalf("AddIncludeIf returned error: %v", err)
+	}
+
+	loaded, err := mgr.LoadExistingIdentities()
+	if err != nil {
+		t.Fatalf("LoadExistingIdentities returned error: %v", err)
+	}
+
+	got, ok := loaded["oss"]
+	if !ok {
+		t.Fatalf("expected identity 'oss' to be loaded, got %v", loaded)
+	}
+	if len(got.Conditions) != 2 {
+		t.Fatalf("Conditions = %v, want 2 entries", got.Conditions)
+	}
+
+	ctxOnBranch := identity.MatchContext{Branch: "release/1.0"}
+	if !got.Matches(ctxOnBranch) {
+		t.Errorf("expected onbranch condition to match branch %q", ctxOnBranch.Branch)
+	}
+	ctxRemoteURL := identity.MatchContext{RemoteURL: "git@github.com:jane/oss-project"}
+	if !got.Matches(ctxRemoteURL) {
+		t.Errorf("expected hasconfig:remote.*.url condition to match %q", ctxRemoteURL.RemoteURL)
+	}
+	if got.Matches(identity.MatchContext{Branch: "main"}) {
+		t.Errorf("onbranch condition should not match an unrelated branch")
+	}
+}
@@ -0,0 +1,42 @@
+// Package gitcmd shells out to the real git binary with fixed argv slices,
+// never a shell or a format string, so callers can't introduce command
+// injection by passing through user-controlled paths, branch names or URLs.
+package gitcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Run executes `git <args...>` in dir and returns its stdout/stderr
+// separately. err wraps the command's own error with the trimmed stderr so
+// callers get a readable message without parsing exec.ExitError themselves.
+func Run(ctx context.Context, dir string, args ...string) (stdout, stderr string, err error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	runErr := cmd.Run()
+	stdout = strings.TrimRight(outBuf.String(), "\n")
+	stderr = strings.TrimRight(errBuf.String(), "\n")
+	if runErr != nil {
+		return stdout, stderr, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), runErr, stderr)
+	}
+
+	return stdout, stderr, nil
+}
+
+// IsUnsetConfigErr reports whether err is the error Run returns for
+// `git config --get <key>` when key is simply unset, i.e. git's own exit
+// code 1, as opposed to a real failure (malformed config, not a repo, etc).
+func IsUnsetConfigErr(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+}
@@ -0,0 +1,163 @@
+package identity
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ConditionKind identifies which flavor of git's includeIf condition a
+// Condition represents. See git-config(1)'s "Conditional includes" section.
+type ConditionKind int
+
+const (
+	ConditionGitdir ConditionKind = iota
+	ConditionGitdirCaseInsensitive
+	ConditionOnBranch
+	ConditionHasConfigRemoteURL
+)
+
+// String renders the condition kind as it is spelled on the CLI.
+func (k ConditionKind) String() string {
+	switch k {
+	case ConditionGitdir:
+		return "gitdir"
+	case ConditionGitdirCaseInsensitive:
+		return "gitdir-i"
+	case ConditionOnBranch:
+		return "onbranch"
+	case ConditionHasConfigRemoteURL:
+		return "remote-url"
+	default:
+		return "unknown"
+	}
+}
+
+const hasConfigRemoteURLPrefix = "hasconfig:remote.*.url:"
+
+// Condition is a single includeIf condition, e.g. `gitdir:~/work/`,
+// `onbranch:release/*` or `hasconfig:remote.*.url:git@github.com:work/**`.
+type Condition struct {
+	Kind    ConditionKind
+	Pattern string
+}
+
+// Key renders the condition back into git's includeIf condition syntax, i.e.
+// the text that goes inside `[includeIf "<Key>"]`.
+func (c Condition) Key() string {
+	switch c.Kind {
+	case ConditionGitdir:
+		return "gitdir:" + c.Pattern
+	case ConditionGitdirCaseInsensitive:
+		return "gitdir/i:" + c.Pattern
+	case ConditionOnBranch:
+		return "onbranch:" + c.Pattern
+	case ConditionHasConfigRemoteURL:
+		return hasConfigRemoteURLPrefix + c.Pattern
+	default:
+		return c.Pattern
+	}
+}
+
+// ParseCondition parses a raw includeIf condition (the string git expects
+// between the quotes of `[includeIf "..."]`) back into a Condition. ok is
+// false for condition kinds GitID doesn't model.
+func ParseCondition(raw string) (cond Condition, ok bool) {
+	switch {
+	case strings.HasPrefix(raw, "gitdir/i:"):
+		return Condition{Kind: ConditionGitdirCaseInsensitive, Pattern: strings.TrimPrefix(raw, "gitdir/i:")}, true
+	case strings.HasPrefix(raw, "gitdir:"):
+		return Condition{Kind: ConditionGitdir, Pattern: strings.TrimPrefix(raw, "gitdir:")}, true
+	case strings.HasPrefix(raw, "onbranch:"):
+		return Condition{Kind: ConditionOnBranch, Pattern: strings.TrimPrefix(raw, "onbranch:")}, true
+	case strings.HasPrefix(raw, hasConfigRemoteURLPrefix):
+		return Condition{Kind: ConditionHasConfigRemoteURL, Pattern: strings.TrimPrefix(raw, hasConfigRemoteURLPrefix)}, true
+	default:
+		return Condition{}, false
+	}
+}
+
+// MatchContext describes the directory, checked-out branch and `origin`
+// remote URL to evaluate an identity's conditions against.
+type MatchContext struct {
+	Path      string
+	Branch    string
+	RemoteURL string
+}
+
+// Matches reports whether ctx satisfies this condition. Branch/RemoteURL
+// conditions never match an empty ctx.Branch/ctx.RemoteURL, since that means
+// the caller has no opinion on them (e.g. `gitid status` outside a repo).
+func (c Condition) Matches(ctx MatchContext) bool {
+	switch c.Kind {
+	case ConditionGitdir:
+		return matchesGitdir(ctx.Path, c.Pattern, false)
+	case ConditionGitdirCaseInsensitive:
+		return matchesGitdir(ctx.Path, c.Pattern, true)
+	case ConditionOnBranch:
+		return ctx.Branch != "" && matchesGlob(c.Pattern, ctx.Branch)
+	case ConditionHasConfigRemoteURL:
+		return ctx.RemoteURL != "" && matchesGlob(c.Pattern, ctx.RemoteURL)
+	default:
+		return false
+	}
+}
+
+func matchesGitdir(testPath, pattern string, caseInsensitive bool) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if caseInsensitive {
+		testPath = strings.ToLower(testPath)
+		pattern = strings.ToLower(pattern)
+	}
+	return testPath == pattern || strings.HasPrefix(testPath, pattern+"/")
+}
+
+// matchesGlob reports whether value matches pattern under a subset of git's
+// own "wildmatch" globbing (see gitglossary(7)): `**` matches any sequence of
+// characters including `/`, `*` matches any sequence of characters except
+// `/`, and `?` matches any single character except `/`. This deliberately
+// does not use path/filepath.Match, which has no `**` support and would
+// reject it as a malformed pattern.
+func matchesGlob(pattern, value string) bool {
+	re, err := regexp.Compile(wildmatchRegexp(pattern))
+	if err != nil {
+		return pattern == value
+	}
+	return re.MatchString(value)
+}
+
+// wildmatchRegexp translates a wildmatch pattern into an equivalent anchored
+// regexp.
+func wildmatchRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
+
+// Matches reports whether any of the identity's conditions are satisfied by ctx.
+func (i *Identity) Matches(ctx MatchContext) bool {
+	for _, cond := range i.Conditions {
+		if cond.Matches(ctx) {
+			return true
+		}
+	}
+	return false
+}
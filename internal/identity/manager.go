@@ -4,31 +4,98 @@ import (
 	"fmt"
 )
 
+// Scope identifies which gitconfig file an identity is declared in, mirroring
+// git's own config scopes (see `git config --show-scope`): system, global,
+// local (the repository's .git/config) and worktree (.git/config.worktree).
+type Scope int
+
+const (
+	GlobalScope Scope = iota
+	SystemScope
+	LocalScope
+	WorktreeScope
+)
+
+// String renders the scope the way it is spelled on the CLI (--scope global).
+func (s Scope) String() string {
+	switch s {
+	case GlobalScope:
+		return "global"
+	case SystemScope:
+		return "system"
+	case LocalScope:
+		return "local"
+	case WorktreeScope:
+		return "worktree"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseScope parses a --scope flag value, defaulting an empty string to GlobalScope.
+func ParseScope(s string) (Scope, error) {
+	switch s {
+	case "", "global":
+		return GlobalScope, nil
+	case "system":
+		return SystemScope, nil
+	case "local":
+		return LocalScope, nil
+	case "worktree":
+		return WorktreeScope, nil
+	default:
+		return GlobalScope, fmt.Errorf("unknown scope %q (want one of: global, system, local, worktree)", s)
+	}
+}
+
 // Identity represents a git identity configuration
 type Identity struct {
-	Name    string   // Identity name (work, personal)
-	GitName string   // Git user.name
-	Email   string   // Git user.email
-	Paths   []string // Directory paths
+	Name       string      // Identity name (work, personal)
+	GitName    string      // Git user.name
+	Email      string      // Git user.email
+	Conditions []Condition // includeIf conditions (gitdir, onbranch, hasconfig:remote.*.url, ...)
+	Scope      Scope       // Which gitconfig file this identity is declared in
+	// RepoPath is the repository root used to resolve LocalScope/WorktreeScope
+	// config files (.git/config, .git/config.worktree). Unused for
+	// GlobalScope/SystemScope.
+	RepoPath string
+
+	// SigningKey is user.signingkey: a GPG key id, an SSH public key path, or
+	// an X.509 subject, depending on SigningFormat.
+	SigningKey string
+	// SigningFormat is gpg.format: "gpg" (the default), "ssh" or "x509".
+	SigningFormat string
+	// SignCommits/SignTags mirror commit.gpgsign/tag.gpgsign.
+	SignCommits bool
+	SignTags    bool
+	// SSHCommand is core.sshCommand, e.g. "ssh -i ~/.ssh/id_work".
+	SSHCommand string
 }
 
 // ConfigManager interface to avoid circular imports
 type ConfigManager interface {
 	AddIncludeIf(identity *Identity) error
-	RemoveIncludeIf(name string) error
+	RemoveIncludeIf(identity *Identity) error
 }
 
+// ConfigManagerFactory resolves the ConfigManager responsible for a given
+// scope. repoPath is only consulted for LocalScope/WorktreeScope, where it
+// points at the repository whose .git/config (or .git/config.worktree)
+// should be read or written.
+type ConfigManagerFactory func(scope Scope, repoPath string) (ConfigManager, error)
+
 // Manager handles identity operations
 type Manager struct {
-	identities    map[string]*Identity
-	configManager ConfigManager
+	identities       map[string]*Identity
+	newConfigManager ConfigManagerFactory
 }
 
-// NewManager creates a new identity manager
-func NewManager(configManager ConfigManager) *Manager {
+// NewManager creates a new identity manager. factory may be nil, in which
+// case identities are only tracked in memory and never persisted.
+func NewManager(factory ConfigManagerFactory) *Manager {
 	return &Manager{
-		identities:    make(map[string]*Identity),
-		configManager: configManager,
+		identities:       make(map[string]*Identity),
+		newConfigManager: factory,
 	}
 }
 
@@ -39,44 +106,45 @@ func (m *Manager) LoadIdentities(identities map[string]*Identity) {
 	m.identities = identities
 }
 
-// AddIdentity adds a new identity
-func (m *Manager) AddIdentity(name, gitName, email string, paths []string) error {
-	if m.identities[name] != nil {
-		return fmt.Errorf("identity '%s' already exists", name)
-	}
-
-	// Create the identity
-	identity := &Identity{
-		Name:    name,
-		GitName: gitName,
-		Email:   email,
-		Paths:   paths,
+// AddIdentity adds ident, a fully-populated identity, to the manager.
+// ident.Scope and ident.RepoPath (for LocalScope/WorktreeScope) determine
+// which gitconfig file it is persisted to.
+func (m *Manager) AddIdentity(ident *Identity) error {
+	if m.identities[ident.Name] != nil {
+		return fmt.Errorf("identity '%s' already exists", ident.Name)
 	}
 
 	// Add to config manager first (this handles Git config persistence)
-	if m.configManager != nil {
-		err := m.configManager.AddIncludeIf(identity)
+	if m.newConfigManager != nil {
+		cm, err := m.newConfigManager(ident.Scope, ident.RepoPath)
 		if err != nil {
+			return fmt.Errorf("failed to resolve config manager for scope %s: %w", ident.Scope, err)
+		}
+		if err := cm.AddIncludeIf(ident); err != nil {
 			return fmt.Errorf("failed to update git config: %w", err)
 		}
 	}
 
 	// Add to in-memory storage only after successful config update
-	m.identities[name] = identity
+	m.identities[ident.Name] = ident
 
 	return nil
 }
 
 // RemoveIdentity removes an identity
 func (m *Manager) RemoveIdentity(name string) error {
-	if m.identities[name] == nil {
+	identity := m.identities[name]
+	if identity == nil {
 		return fmt.Errorf("identity '%s' not found", name)
 	}
 
 	// Remove from config manager first (this handles Git config cleanup)
-	if m.configManager != nil {
-		err := m.configManager.RemoveIncludeIf(name)
+	if m.newConfigManager != nil {
+		cm, err := m.newConfigManager(identity.Scope, identity.RepoPath)
 		if err != nil {
+			return fmt.Errorf("failed to resolve config manager for scope %s: %w", identity.Scope, err)
+		}
+		if err := cm.RemoveIncludeIf(identity); err != nil {
 			return fmt.Errorf("failed to remove from git config: %w", err)
 		}
 	}
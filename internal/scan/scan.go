@@ -0,0 +1,247 @@
+// Package scan walks a directory tree looking for existing git repositories
+// and classifies each one against a set of GitID identities, so that GitID
+// can be adopted on a machine that already has dozens of repos rather than
+// only a greenfield setup.
+package scan
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Kir-Push/GitID/internal/gitcmd"
+	"github.com/Kir-Push/GitID/internal/identity"
+)
+
+// Classification describes how a discovered repository relates to the
+// configured GitID identities.
+type Classification int
+
+const (
+	// MatchesByPath means the repo is under an identity's gitdir condition
+	// and its local .git/config does not set a different user.email. The
+	// repo's effective user.name/user.email are not compared to the identity.
+	MatchesByPath Classification = iota
+	// MatchesByEmailOutsidePath means the repo's effective user.email
+	// matches an identity, but the repo isn't covered by any of that
+	// identity's gitdir conditions.
+	MatchesByEmailOutsidePath
+	// UnmanagedEmail means the repo's effective user.email doesn't match
+	// any configured identity at all.
+	UnmanagedEmail
+	// LocallyShadowed means the repo is under an identity's gitdir
+	// condition, but its local .git/config sets its own user.email that
+	// overrides the identity.
+	LocallyShadowed
+)
+
+// String renders the classification the way it is reported on the CLI.
+func (c Classification) String() string {
+	switch c {
+	case MatchesByPath:
+		return "matches by path"
+	case MatchesByEmailOutsidePath:
+		return "matches by email, outside declared path"
+	case UnmanagedEmail:
+		return "unmanaged email"
+	case LocallyShadowed:
+		return "locally shadowed"
+	default:
+		return "unknown"
+	}
+}
+
+// Repo is one discovered git repository and how it classifies against the
+// identities it was scanned with.
+type Repo struct {
+	Path           string
+	Name           string // effective user.name
+	Email          string // effective user.email
+	Classification Classification
+	// Identity is the identity name most relevant to the classification:
+	// the path-matching identity for MatchesByPath/LocallyShadowed, or the
+	// email-matching identity for MatchesByEmailOutsidePath. Empty for
+	// UnmanagedEmail.
+	Identity string
+}
+
+// defaultIgnoredDirs are directory names never descended into, regardless of
+// .gitignore content: they're either huge, or (for .git) not a real repo
+// root to recurse through.
+var defaultIgnoredDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Walk walks root up to maxDepth directories deep (0 means unlimited),
+// skipping directories matched by a .gitignore in their parent, and returns
+// every discovered repository classified against identities.
+func Walk(ctx context.Context, root string, maxDepth int, identities map[string]*identity.Identity) ([]Repo, error) {
+	var repos []Repo
+
+	var walk func(dir string, depth int, ignores []ignoreRule) error
+	walk = func(dir string, depth int, ignores []ignoreRule) error {
+		ignores = append(ignores, loadGitignore(dir)...)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil // unreadable directory; skip it rather than fail the whole scan
+		}
+
+		isRepo := false
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() == ".git" {
+				isRepo = true
+				break
+			}
+		}
+
+		if isRepo {
+			repo, err := classify(ctx, dir, identities)
+			if err == nil {
+				repos = append(repos, repo)
+			}
+		}
+
+		if maxDepth > 0 && depth >= maxDepth {
+			return nil
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || defaultIgnoredDirs[entry.Name()] {
+				continue
+			}
+			sub := filepath.Join(dir, entry.Name())
+			if matchesAny(ignores, entry.Name()) {
+				continue
+			}
+			if err := walk(sub, depth+1, ignores); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(root, 0, nil); err != nil {
+		return nil, err
+	}
+
+	return repos, nil
+}
+
+// classify reads repoDir's effective and local-only git identity and
+// compares it against identities to produce a Repo classification.
+func classify(ctx context.Context, repoDir string, identities map[string]*identity.Identity) (Repo, error) {
+	name, err := getConfig(ctx, repoDir, "user.name")
+	if err != nil {
+		return Repo{}, err
+	}
+	email, err := getConfig(ctx, repoDir, "user.email")
+	if err != nil {
+		return Repo{}, err
+	}
+	localEmail, err := getLocalConfig(ctx, repoDir, "user.email")
+	if err != nil {
+		return Repo{}, err
+	}
+
+	pathIdentity := matchingPathIdentity(repoDir, identities)
+	emailIdentity := matchingEmailIdentity(email, identities)
+
+	repo := Repo{Path: repoDir, Name: name, Email: email}
+
+	switch {
+	case pathIdentity != nil && localEmail != "" && localEmail != pathIdentity.Email:
+		repo.Classification = LocallyShadowed
+		repo.Identity = pathIdentity.Name
+	case pathIdentity != nil:
+		repo.Classification = MatchesByPath
+		repo.Identity = pathIdentity.Name
+	case emailIdentity != nil:
+		repo.Classification = MatchesByEmailOutsidePath
+		repo.Identity = emailIdentity.Name
+	default:
+		repo.Classification = UnmanagedEmail
+	}
+
+	return repo, nil
+}
+
+func matchingPathIdentity(repoDir string, identities map[string]*identity.Identity) *identity.Identity {
+	ctx := identity.MatchContext{Path: repoDir}
+	for _, ident := range identities {
+		if ident.Matches(ctx) {
+			return ident
+		}
+	}
+	return nil
+}
+
+func matchingEmailIdentity(email string, identities map[string]*identity.Identity) *identity.Identity {
+	if email == "" {
+		return nil
+	}
+	for _, ident := range identities {
+		if ident.Email == email {
+			return ident
+		}
+	}
+	return nil
+}
+
+func getConfig(ctx context.Context, dir, key string) (string, error) {
+	value, _, err := gitcmd.Run(ctx, dir, "config", "--get", key)
+	if err != nil && !gitcmd.IsUnsetConfigErr(err) {
+		return "", err
+	}
+	return value, nil
+}
+
+func getLocalConfig(ctx context.Context, dir, key string) (string, error) {
+	value, _, err := gitcmd.Run(ctx, dir, "config", "--local", "--get", key)
+	if err != nil && !gitcmd.IsUnsetConfigErr(err) {
+		return "", err
+	}
+	return value, nil
+}
+
+// ignoreRule is a single non-negated .gitignore pattern, matched with
+// filepath.Match against a bare file/directory name: `*`, `?` and `[...]`
+// wildcards work, but a pattern with a `/` inside it (a nested path or `**/`)
+// never matches in this best-effort scan.
+type ignoreRule struct {
+	pattern string
+}
+
+func loadGitignore(dir string) []ignoreRule {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		rules = append(rules, ignoreRule{pattern: strings.Trim(line, "/")})
+	}
+
+	return rules
+}
+
+func matchesAny(rules []ignoreRule, name string) bool {
+	for _, rule := range rules {
+		if ok, _ := filepath.Match(rule.pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}